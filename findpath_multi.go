@@ -0,0 +1,399 @@
+package detour
+
+/// Finds the shortest path from any one of several start positions to a
+/// single end position.
+///
+/// This is the multi-source counterpart of dtNavMeshQuery.findPath(): the
+/// open list is seeded with every entry in startRefs/startPos, each pushed
+/// with a g-cost of 0 and its own heuristic to endPos, and a single A*
+/// search runs to completion. The returned path is the globally cheapest
+/// one from any source, which is far cheaper than running len(startRefs)
+/// independent FindPath() calls when a unit could depart from any of
+/// several doors, spawn points, or squad members.
+///
+///  @param[in]		startRefs	The reference ids of the start polygons, one per start position.
+///  @param[in]		startPos	The start positions, one per startRefs entry. [(x, y, z) * len(startRefs)]
+///  @param[in]		endRef		The reference id of the end polygon.
+///  @param[in]		endPos		The end position. [(x, y, z)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	path		An ordered list of polygon references representing the path. (Start to end.)
+///  @returns The path length, in polygons, and the status flags for the query.
+func (q *dtNavMeshQuery) FindPathFromMultipleStarts(
+	startRefs []dtPolyRef, startPos [][3]float32,
+	endRef dtPolyRef, endPos []float32,
+	filter DtQueryFilter,
+	path []dtPolyRef) (int32, dtStatus) {
+
+	if len(startRefs) != len(startPos) {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+	if len(startRefs) == 0 || endRef == 0 || path == nil {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+	if !q.m_nav.IsValidPolyRef(endRef) {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+	for _, sr := range startRefs {
+		if !q.m_nav.IsValidPolyRef(sr) {
+			return 0, DT_FAILURE | DT_INVALID_PARAM
+		}
+	}
+
+	if len(startRefs) == 1 && startRefs[0] == endRef {
+		path[0] = endRef
+		return 1, DT_SUCCESS
+	}
+
+	startRefs, startPos = dedupStartRefs(startRefs, startPos, endPos)
+
+	q.m_nodePool.clear()
+	q.m_openList.clear()
+
+	heuristicScale := filter.GetHeuristicScale()
+
+	// Seed the open list with every start node, each with its own g-cost
+	// of 0 and heuristic to the single goal. The heuristic stays
+	// distance-to-goal (not distance-to-nearest-start), so admissibility
+	// of the overall search is preserved.
+	for i, sr := range startRefs {
+		startNode := q.m_nodePool.getNode(sr, 0)
+		dtVcopy(startNode.pos[:], startPos[i][:])
+		startNode.pidx = 0
+		startNode.cost = 0
+		startNode.total = dtVdist(startPos[i][:], endPos) * heuristicScale
+		startNode.id = sr
+		startNode.flags = DT_NODE_OPEN
+		q.m_openList.push(startNode)
+	}
+
+	// The seed closest to the goal wins the partial-result fallback if
+	// the search never reaches endRef, not whichever start happens to be
+	// listed first in startRefs.
+	lastBestNode := q.m_nodePool.getNode(startRefs[0], 0)
+	lastBestNodeCost := dtVdist(startPos[0][:], endPos) * heuristicScale
+	for i := 1; i < len(startRefs); i++ {
+		if h := dtVdist(startPos[i][:], endPos) * heuristicScale; h < lastBestNodeCost {
+			lastBestNodeCost = h
+			lastBestNode = q.m_nodePool.getNode(startRefs[i], 0)
+		}
+	}
+
+	isEnd := func(ref dtPolyRef) bool { return ref == endRef }
+	heuristic := func(pos []float32) float32 { return dtVdist(pos, endPos) * heuristicScale }
+
+	lastBestNode, status := q.runMultiAStar(filter, isEnd, heuristic, lastBestNode, lastBestNodeCost)
+
+	pathCount, rstatus := q.reconstructPath(lastBestNode, path)
+	return pathCount, status | rstatus
+}
+
+/// Finds the shortest path from a single start position to any one of
+/// several end positions.
+///
+/// Symmetric to FindPathFromMultipleStarts(): here it's the goal side that
+/// fans out, so every reachable endRefs/endPos entry acts as an admissible
+/// target and the search stops as soon as any one of them is popped off
+/// the open list.
+///
+///  @param[in]		startRef	The reference id of the start polygon.
+///  @param[in]		startPos	The start position. [(x, y, z)]
+///  @param[in]		endRefs		The reference ids of the end polygons, one per end position.
+///  @param[in]		endPos		The end positions, one per endRefs entry. [(x, y, z) * len(endRefs)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	path		An ordered list of polygon references representing the path. (Start to end.)
+///  @returns The path length, in polygons, and the status flags for the query.
+func (q *dtNavMeshQuery) FindPathToMultipleEnds(
+	startRef dtPolyRef, startPos []float32,
+	endRefs []dtPolyRef, endPos [][3]float32,
+	filter DtQueryFilter,
+	path []dtPolyRef) (int32, dtStatus) {
+
+	if len(endRefs) != len(endPos) {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+	if len(endRefs) == 0 || startRef == 0 || path == nil {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+	if !q.m_nav.IsValidPolyRef(startRef) {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	// The heuristic to a fan of goals is the minimum distance to any one
+	// of them, scaled by the filter's heuristic scale for weighted A*;
+	// this keeps it admissible at scale 1.0 while letting the single A*
+	// run stop at whichever goal is reached first.
+	heuristicScale := filter.GetHeuristicScale()
+	heuristic := func(pos []float32) float32 {
+		best := dtVdist(pos, endPos[0][:])
+		for i := 1; i < len(endPos); i++ {
+			if d := dtVdist(pos, endPos[i][:]); d < best {
+				best = d
+			}
+		}
+		return best * heuristicScale
+	}
+	isEnd := func(ref dtPolyRef) bool {
+		for _, er := range endRefs {
+			if er == ref {
+				return true
+			}
+		}
+		return false
+	}
+
+	q.m_nodePool.clear()
+	q.m_openList.clear()
+
+	startNode := q.m_nodePool.getNode(startRef, 0)
+	dtVcopy(startNode.pos[:], startPos)
+	startNode.pidx = 0
+	startNode.cost = 0
+	startNode.total = heuristic(startPos)
+	startNode.id = startRef
+	startNode.flags = DT_NODE_OPEN
+	q.m_openList.push(startNode)
+
+	lastBestNode, status := q.runMultiAStar(filter, isEnd, heuristic, startNode, startNode.total)
+
+	pathCount, rstatus := q.reconstructPath(lastBestNode, path)
+	return pathCount, status | rstatus
+}
+
+/// Intializes a sliced multi-source path query, allowing the search to be
+/// completed incrementally over several calls to updateSlicedFindPath().
+///
+/// Behaves like initSlicedFindPath(), except the open list is seeded from
+/// every entry in startRefs/startPos rather than a single start, using the
+/// same per-node bookkeeping as FindPathFromMultipleStarts(). Useful when a
+/// multi-source query is itself too expensive to run to completion in a
+/// single frame.
+///
+///  @param[in]		startRefs	The reference ids of the start polygons, one per start position.
+///  @param[in]		startPos	The start positions, one per startRefs entry. [(x, y, z) * len(startRefs)]
+///  @param[in]		endRef		The reference id of the end polygon.
+///  @param[in]		endPos		The end position. [(x, y, z)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+func (q *dtNavMeshQuery) initSlicedFindPathFromMultipleStarts(
+	startRefs []dtPolyRef, startPos [][3]float32,
+	endRef dtPolyRef, endPos []float32,
+	filter DtQueryFilter) dtStatus {
+
+	q.m_query.status = DT_FAILURE
+	q.m_query.startRef = 0
+	q.m_query.endRef = endRef
+	q.m_query.filter = filter
+
+	if len(startRefs) != len(startPos) || len(startRefs) == 0 || endRef == 0 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	if !q.m_nav.IsValidPolyRef(endRef) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	for _, sr := range startRefs {
+		if !q.m_nav.IsValidPolyRef(sr) {
+			return DT_FAILURE | DT_INVALID_PARAM
+		}
+	}
+
+	startRefs, startPos = dedupStartRefs(startRefs, startPos, endPos)
+
+	// The single-start fields on m_query still record the winning start
+	// once the slice completes; until then startRef stays unset.
+	q.m_nodePool.clear()
+	q.m_openList.clear()
+
+	heuristicScale := filter.GetHeuristicScale()
+	for i, sr := range startRefs {
+		startNode := q.m_nodePool.getNode(sr, 0)
+		dtVcopy(startNode.pos[:], startPos[i][:])
+		startNode.pidx = 0
+		startNode.cost = 0
+		startNode.total = dtVdist(startPos[i][:], endPos) * heuristicScale
+		startNode.id = sr
+		startNode.flags = DT_NODE_OPEN
+		q.m_openList.push(startNode)
+	}
+
+	lastBestNode := q.m_nodePool.getNode(startRefs[0], 0)
+	lastBestNodeCost := dtVdist(startPos[0][:], endPos) * heuristicScale
+	for i := 1; i < len(startRefs); i++ {
+		if h := dtVdist(startPos[i][:], endPos) * heuristicScale; h < lastBestNodeCost {
+			lastBestNodeCost = h
+			lastBestNode = q.m_nodePool.getNode(startRefs[i], 0)
+		}
+	}
+
+	q.m_query.status = DT_IN_PROGRESS
+	q.m_query.lastBestNode = lastBestNode
+	q.m_query.lastBestNodeCost = lastBestNodeCost
+
+	return q.m_query.status
+}
+
+// dedupStartRefs removes duplicate entries in startRefs, keeping for each
+// distinct ref only the one closest to endPos. Every seed starts with the
+// same g-cost of 0, so the closer entry is also the one with the lower
+// heuristic/total; seeding the same ref twice would otherwise fetch the
+// same dtNode from the pool both times, letting the second entry silently
+// overwrite the first one's position and push that node onto the open
+// list twice. Duplicate refs arise naturally when several start
+// positions - e.g. squad members standing on the same polygon - share a
+// dtPolyRef.
+func dedupStartRefs(startRefs []dtPolyRef, startPos [][3]float32, endPos []float32) ([]dtPolyRef, [][3]float32) {
+	best := make(map[dtPolyRef]int, len(startRefs))
+	for i, sr := range startRefs {
+		j, ok := best[sr]
+		if !ok || dtVdist(startPos[i][:], endPos) < dtVdist(startPos[j][:], endPos) {
+			best[sr] = i
+		}
+	}
+	if len(best) == len(startRefs) {
+		return startRefs, startPos
+	}
+
+	refs := make([]dtPolyRef, 0, len(best))
+	pos := make([][3]float32, 0, len(best))
+	for i, sr := range startRefs {
+		if best[sr] == i {
+			refs = append(refs, sr)
+			pos = append(pos, startPos[i])
+		}
+	}
+	return refs, pos
+}
+
+// runMultiAStar runs the A* expansion loop shared by
+// FindPathFromMultipleStarts and FindPathToMultipleEnds: tile/poly lookup,
+// parent lookup, neighbour expansion, and g/h bookkeeping. isEnd reports
+// whether a popped node is an acceptable goal; heuristic estimates the
+// remaining cost from a position to the nearest goal. The caller seeds
+// q.m_nodePool/q.m_openList (and the initial lastBestNode/lastBestNodeCost)
+// before calling this, since seeding differs between a fan of starts and a
+// single start.
+func (q *dtNavMeshQuery) runMultiAStar(
+	filter DtQueryFilter,
+	isEnd func(ref dtPolyRef) bool,
+	heuristic func(pos []float32) float32,
+	lastBestNode *dtNode,
+	lastBestNodeCost float32) (*dtNode, dtStatus) {
+
+	status := dtStatus(DT_SUCCESS)
+
+	for !q.m_openList.empty() {
+		bestNode := q.m_openList.pop()
+		bestNode.flags &^= DT_NODE_OPEN
+		bestNode.flags |= DT_NODE_CLOSED
+
+		bestRef := bestNode.id
+		if isEnd(bestRef) {
+			lastBestNode = bestNode
+			break
+		}
+
+		var bestTile *DtMeshTile
+		var bestPoly *DtPoly
+		q.m_nav.GetTileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		var parentRef dtPolyRef
+		var parentTile *DtMeshTile
+		var parentPoly *DtPoly
+		if bestNode.pidx != 0 {
+			parentRef = q.m_nodePool.getNodeAtIdx(bestNode.pidx).id
+			q.m_nav.GetTileAndPolyByRefUnsafe(parentRef, &parentTile, &parentPoly)
+		}
+
+		for i := bestPoly.FirstLink; i != DT_NULL_LINK; i = bestTile.Links[i].Next {
+			neighbourRef := bestTile.Links[i].Ref
+			if neighbourRef == 0 || neighbourRef == parentRef {
+				continue
+			}
+			if !filter.IsValidLinkSide(bestTile.Links[i].Side) {
+				continue
+			}
+
+			var neighbourTile *DtMeshTile
+			var neighbourPoly *DtPoly
+			q.m_nav.GetTileAndPolyByRefUnsafe(neighbourRef, &neighbourTile, &neighbourPoly)
+
+			if !filter.PassFilter(neighbourRef, neighbourTile, neighbourPoly) {
+				continue
+			}
+
+			neighbourNode := q.m_nodePool.getNode(neighbourRef, 0)
+
+			var sa, sb [3]float32
+			q.getEdgeMidPoint(bestRef, bestPoly, bestTile,
+				neighbourRef, neighbourPoly, neighbourTile, sa[:], sb[:])
+
+			cost := filter.GetCost(bestNode.pos[:], sa[:],
+				parentRef, parentTile, parentPoly,
+				bestRef, bestTile, bestPoly,
+				neighbourRef, neighbourTile, neighbourPoly)
+
+			total := bestNode.cost + cost
+
+			// The best-of-all-starts g-cost wins; a node already closed
+			// with a cheaper total is never reopened.
+			if (neighbourNode.flags&DT_NODE_CLOSED) != 0 && total >= neighbourNode.cost {
+				continue
+			}
+			if (neighbourNode.flags&DT_NODE_OPEN) != 0 && total >= neighbourNode.cost {
+				continue
+			}
+
+			neighbourNode.id = neighbourRef
+			neighbourNode.pidx = q.m_nodePool.getNodeIdx(bestNode)
+			dtVcopy(neighbourNode.pos[:], sa[:])
+			neighbourNode.cost = total
+			h := heuristic(sa[:])
+			neighbourNode.total = total + h
+
+			if h < lastBestNodeCost {
+				lastBestNodeCost = h
+				lastBestNode = neighbourNode
+			}
+
+			if (neighbourNode.flags & DT_NODE_OPEN) != 0 {
+				q.m_openList.modify(neighbourNode)
+			} else {
+				neighbourNode.flags = DT_NODE_OPEN
+				q.m_openList.push(neighbourNode)
+			}
+		}
+	}
+
+	if !isEnd(lastBestNode.id) {
+		status |= DT_PARTIAL_RESULT
+	}
+
+	return lastBestNode, status
+}
+
+// reconstructPath walks parent indices back from lastBestNode to the seed
+// node (pidx == 0), writes the result into path in start-to-end order, and
+// reports DT_BUFFER_TOO_SMALL if path is too short to hold it. Shared by
+// FindPathFromMultipleStarts and FindPathToMultipleEnds.
+func (q *dtNavMeshQuery) reconstructPath(lastBestNode *dtNode, path []dtPolyRef) (int32, dtStatus) {
+	var status dtStatus
+	var pathCount int32
+	node := lastBestNode
+	for node != nil {
+		if pathCount >= int32(len(path)) {
+			status |= DT_BUFFER_TOO_SMALL
+			break
+		}
+		path[pathCount] = node.id
+		pathCount++
+		if node.pidx == 0 {
+			break
+		}
+		node = q.m_nodePool.getNodeAtIdx(node.pidx)
+	}
+
+	// Path was recovered goal-to-winning-start; reverse it in place.
+	for i, j := int32(0), pathCount-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return pathCount, status
+}
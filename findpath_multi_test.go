@@ -0,0 +1,41 @@
+package detour
+
+import "testing"
+
+func TestDedupStartRefsKeepsClosestOfDuplicateRefs(t *testing.T) {
+	endPos := []float32{10, 0, 0}
+	startRefs := []dtPolyRef{1, 2, 1}
+	startPos := [][3]float32{
+		{0, 0, 0}, // ref 1, far from goal
+		{9, 0, 0}, // ref 2, close to goal
+		{8, 0, 0}, // ref 1 again, closer to goal than the first entry
+	}
+
+	refs, pos := dedupStartRefs(startRefs, startPos, endPos)
+
+	if len(refs) != 2 {
+		t.Fatalf("len(refs) = %d, want 2 after deduping ref 1", len(refs))
+	}
+	for i, r := range refs {
+		if r == 1 && pos[i] != startPos[2] {
+			t.Fatalf("deduped position for ref 1 = %v, want %v (the entry closest to endPos)", pos[i], startPos[2])
+		}
+	}
+}
+
+func TestDedupStartRefsLeavesDistinctRefsUntouched(t *testing.T) {
+	endPos := []float32{10, 0, 0}
+	startRefs := []dtPolyRef{1, 2, 3}
+	startPos := [][3]float32{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}}
+
+	refs, pos := dedupStartRefs(startRefs, startPos, endPos)
+
+	if len(refs) != 3 {
+		t.Fatalf("len(refs) = %d, want 3 when all refs are already distinct", len(refs))
+	}
+	for i := range refs {
+		if refs[i] != startRefs[i] || pos[i] != startPos[i] {
+			t.Fatalf("entry %d = (%v, %v), want unchanged (%v, %v)", i, refs[i], pos[i], startRefs[i], startPos[i])
+		}
+	}
+}
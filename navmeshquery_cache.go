@@ -0,0 +1,178 @@
+package detour
+
+import (
+	"container/list"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+/// CachedNavMeshQuery wraps a dtNavMeshQuery with an LRU cache of recent
+/// FindPath() results, keyed by (startRef, endRef, filter fingerprint).
+/// This is extremely common in RTS/MMO servers where many units share a
+/// filter and endpoints, and is far cheaper than re-running A* for every
+/// unit asking for the same route.
+///
+/// filterFingerprint() is a stable hash over the filter's include/exclude
+/// flags, all area costs, and any extension fields (backtracking, fixed
+/// costs, heuristic scale, valid link sides); DtQueryFilter.Equals() is
+/// used to confirm a hash hit is a real match rather than a collision.
+type CachedNavMeshQuery struct {
+	inner    pathFinder
+	capacity int
+	entries  map[cacheKey]*list.Element
+	order    *list.List // front = most recently used
+}
+
+// pathFinder is the subset of dtNavMeshQuery's surface CachedNavMeshQuery
+// needs. Kept as an interface, rather than depending on *dtNavMeshQuery
+// directly, purely so the cache's hit/miss/eviction logic can be unit
+// tested against a fake, without needing a real dtNavMesh.
+type pathFinder interface {
+	FindPath(startRef, endRef dtPolyRef, startPos, endPos []float32,
+		filter DtQueryFilter, path []dtPolyRef, maxPath int32) (int32, dtStatus)
+}
+
+type cacheKey struct {
+	startRef    dtPolyRef
+	endRef      dtPolyRef
+	fingerprint uint64
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	filter DtQueryFilter
+	path   []dtPolyRef
+	status dtStatus
+}
+
+/// NewCachedNavMeshQuery wraps inner with a path cache holding up to
+/// capacity entries, evicting the least recently used entry once full.
+func NewCachedNavMeshQuery(inner *dtNavMeshQuery, capacity int) *CachedNavMeshQuery {
+	return newCachedNavMeshQuery(inner, capacity)
+}
+
+// newCachedNavMeshQuery builds a CachedNavMeshQuery around any pathFinder,
+// not just *dtNavMeshQuery. Split out from NewCachedNavMeshQuery so tests
+// can drive the cache against a fake pathFinder.
+func newCachedNavMeshQuery(inner pathFinder, capacity int) *CachedNavMeshQuery {
+	return &CachedNavMeshQuery{
+		inner:    inner,
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+/// FindPath returns the cached result for (startRef, endRef, filter) if
+/// one exists, otherwise delegates to the wrapped query and caches the
+/// result for next time. A result whose status is a failure is returned
+/// without being cached.
+///  @param[in]		startRef	The reference id of the start polygon.
+///  @param[in]		endRef		The reference id of the end polygon.
+///  @param[in]		startPos	A position within the start polygon. [(x, y, z)]
+///  @param[in]		endPos		A position within the end polygon. [(x, y, z)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	path		An ordered list of polygon references representing the path. (Start to end.)
+func (c *CachedNavMeshQuery) FindPath(startRef, endRef dtPolyRef, startPos, endPos []float32,
+	filter DtQueryFilter, path []dtPolyRef) (int32, dtStatus) {
+
+	key := cacheKey{startRef: startRef, endRef: endRef, fingerprint: filterFingerprint(filter)}
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if entry.filter.Equals(filter) {
+			c.order.MoveToFront(elem)
+			n := copy(path, entry.path)
+			return int32(n), cacheHitStatus(entry.status, len(entry.path), len(path))
+		}
+		// Fingerprint collision between two distinct filters: fall through
+		// and recompute, then overwrite the stale entry below.
+	}
+
+	maxPath := int32(len(path))
+	n, status := c.inner.FindPath(startRef, endRef, startPos, endPos, filter, path, maxPath)
+	if dtStatusFailed(status) {
+		return n, status
+	}
+
+	cached := make([]dtPolyRef, n)
+	copy(cached, path[:n])
+	c.put(key, &cacheEntry{key: key, filter: filter, path: cached, status: status})
+
+	return n, status
+}
+
+// cacheHitStatus reports status with DT_BUFFER_TOO_SMALL added whenever
+// the caller's buffer (bufLen) is too short to hold the full cached path
+// (cachedLen), matching the behavior callers get from an uncached
+// dtNavMeshQuery.FindPath() on the same undersized buffer.
+func cacheHitStatus(status dtStatus, cachedLen, bufLen int) dtStatus {
+	if bufLen < cachedLen {
+		return status | DT_BUFFER_TOO_SMALL
+	}
+	return status
+}
+
+func (c *CachedNavMeshQuery) put(key cacheKey, entry *cacheEntry) {
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	elem := c.order.PushFront(entry)
+	c.entries[key] = elem
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+}
+
+/// Invalidate drops every cached path. It is not wired to any automatic
+/// trigger: the owner is responsible for calling it whenever a tile is
+/// added to or removed from the underlying dtNavMesh, since either can
+/// change which polygons are reachable from a cached start/end pair. A
+/// long-lived cache that never calls this after a mesh mutation will
+/// silently serve stale paths.
+func (c *CachedNavMeshQuery) Invalidate() {
+	c.entries = make(map[cacheKey]*list.Element, c.capacity)
+	c.order.Init()
+}
+
+// filterFingerprint hashes the parts of filter that affect PassFilter()
+// and GetCost() results: flags, area costs, and the extension fields
+// added for backtracking, fixed costs, heuristic scale, and valid link
+// sides. Any DtQueryFilter implementation can be fingerprinted this way,
+// since it is read entirely through the interface.
+func filterFingerprint(filter DtQueryFilter) uint64 {
+	h := fnv.New64a()
+	var buf [4]byte
+	writeU32 := func(v uint32) {
+		binary.LittleEndian.PutUint32(buf[:], v)
+		h.Write(buf[:])
+	}
+	writeF32 := func(v float32) { writeU32(math.Float32bits(v)) }
+
+	writeU32(uint32(filter.GetIncludeFlags()))
+	writeU32(uint32(filter.GetExcludeFlags()))
+	for i := int32(0); i < DT_MAX_AREAS; i++ {
+		writeF32(filter.GetAreaCost(i))
+		writeF32(filter.GetAreaFixedCost(i))
+	}
+	writeF32(filter.GetHeuristicScale())
+	if filter.GetIsBacktracking() {
+		writeU32(1)
+	}
+	var sides uint32
+	for side := uint8(0); side < 8; side++ {
+		if filter.IsValidLinkSide(side) {
+			sides |= 1 << side
+		}
+	}
+	writeU32(sides)
+
+	return h.Sum64()
+}
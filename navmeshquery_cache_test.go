@@ -0,0 +1,119 @@
+package detour
+
+import "testing"
+
+// fakePathFinder is a pathFinder test double that returns a canned
+// path/status and counts calls, so CachedNavMeshQuery's hit/miss/eviction
+// behavior can be pinned down without a real dtNavMeshQuery.
+type fakePathFinder struct {
+	calls  int
+	path   []dtPolyRef
+	status dtStatus
+}
+
+func (f *fakePathFinder) FindPath(startRef, endRef dtPolyRef, startPos, endPos []float32,
+	filter DtQueryFilter, path []dtPolyRef, maxPath int32) (int32, dtStatus) {
+	f.calls++
+	n := copy(path, f.path)
+	return int32(n), f.status
+}
+
+func TestCachedNavMeshQueryFindPathHitsCacheOnSecondCall(t *testing.T) {
+	fake := &fakePathFinder{path: []dtPolyRef{1, 2, 3}, status: DT_SUCCESS}
+	c := newCachedNavMeshQuery(fake, 4)
+	filter := NewDtQueryFilter()
+	path := make([]dtPolyRef, 3)
+	start, end := []float32{0, 0, 0}, []float32{1, 1, 1}
+
+	n, status := c.FindPath(10, 20, start, end, filter, path)
+	if n != 3 || status != DT_SUCCESS || fake.calls != 1 {
+		t.Fatalf("first call: n=%d status=%v calls=%d, want n=3 status=DT_SUCCESS calls=1", n, status, fake.calls)
+	}
+
+	n, status = c.FindPath(10, 20, start, end, filter, path)
+	if n != 3 || status != DT_SUCCESS || fake.calls != 1 {
+		t.Fatalf("second call: n=%d status=%v calls=%d, want n=3 status=DT_SUCCESS calls=1 (cache hit, no recompute)", n, status, fake.calls)
+	}
+}
+
+func TestCachedNavMeshQueryFindPathMissesOnDifferentFilter(t *testing.T) {
+	fake := &fakePathFinder{path: []dtPolyRef{1, 2}, status: DT_SUCCESS}
+	c := newCachedNavMeshQuery(fake, 4)
+	a := NewDtQueryFilter()
+	b := NewDtQueryFilter()
+	b.SetIncludeFlags(0x1)
+	path := make([]dtPolyRef, 2)
+	start, end := []float32{0, 0, 0}, []float32{1, 1, 1}
+
+	c.FindPath(10, 20, start, end, a, path)
+	c.FindPath(10, 20, start, end, b, path)
+	if fake.calls != 2 {
+		t.Fatalf("calls = %d, want 2 (same start/end but different filters must not share a cache entry)", fake.calls)
+	}
+}
+
+func TestCachedNavMeshQueryInvalidateForcesRecompute(t *testing.T) {
+	fake := &fakePathFinder{path: []dtPolyRef{1}, status: DT_SUCCESS}
+	c := newCachedNavMeshQuery(fake, 4)
+	filter := NewDtQueryFilter()
+	path := make([]dtPolyRef, 1)
+	start, end := []float32{0, 0, 0}, []float32{1, 1, 1}
+
+	c.FindPath(10, 20, start, end, filter, path)
+	c.Invalidate()
+	c.FindPath(10, 20, start, end, filter, path)
+	if fake.calls != 2 {
+		t.Fatalf("calls = %d, want 2 (Invalidate must force a recompute on the next call)", fake.calls)
+	}
+}
+
+func TestCachedNavMeshQueryEvictsLeastRecentlyUsed(t *testing.T) {
+	fake := &fakePathFinder{path: []dtPolyRef{1}, status: DT_SUCCESS}
+	c := newCachedNavMeshQuery(fake, 2)
+	filter := NewDtQueryFilter()
+	path := make([]dtPolyRef, 1)
+	start, end := []float32{0, 0, 0}, []float32{1, 1, 1}
+
+	c.FindPath(1, 2, start, end, filter, path) // key A
+	c.FindPath(3, 4, start, end, filter, path) // key B
+	c.FindPath(5, 6, start, end, filter, path) // key C, over capacity 2: evicts A
+
+	if fake.calls != 3 {
+		t.Fatalf("calls = %d, want 3 after populating 3 distinct keys", fake.calls)
+	}
+
+	c.FindPath(1, 2, start, end, filter, path) // key A should have been evicted
+	if fake.calls != 4 {
+		t.Fatalf("calls = %d, want 4: key A should have been evicted by key C and recomputed", fake.calls)
+	}
+}
+
+func TestCacheHitStatusFlagsBufferTooSmall(t *testing.T) {
+	status := cacheHitStatus(DT_SUCCESS, 5, 3)
+	if status&DT_BUFFER_TOO_SMALL == 0 {
+		t.Fatalf("cacheHitStatus(_, cachedLen=5, bufLen=3) = %v, want DT_BUFFER_TOO_SMALL set", status)
+	}
+}
+
+func TestCacheHitStatusUnchangedWhenBufferFits(t *testing.T) {
+	status := cacheHitStatus(DT_SUCCESS, 3, 5)
+	if status&DT_BUFFER_TOO_SMALL != 0 {
+		t.Fatalf("cacheHitStatus(_, cachedLen=3, bufLen=5) = %v, want DT_BUFFER_TOO_SMALL unset", status)
+	}
+	if status != DT_SUCCESS {
+		t.Fatalf("cacheHitStatus(DT_SUCCESS, 3, 5) = %v, want unchanged DT_SUCCESS", status)
+	}
+}
+
+func TestFilterFingerprintDiffersOnExtensionFields(t *testing.T) {
+	a := NewDtQueryFilter()
+	b := NewDtQueryFilter()
+	if filterFingerprint(a) != filterFingerprint(b) {
+		t.Fatal("two freshly constructed filters should fingerprint the same")
+	}
+
+	b.SetIsValidLinkSide(4, false)
+	if filterFingerprint(a) == filterFingerprint(b) {
+		t.Fatal("filters differing only in valid link sides should fingerprint differently")
+	}
+}
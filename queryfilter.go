@@ -2,7 +2,6 @@ package detour
 
 /// Defines polygon filtering and traversal costs for navigation mesh query operations.
 /// @ingroup detour
-/// @class dtQueryFilter
 ///
 /// <b>The Default Implementation</b>
 ///
@@ -19,12 +18,19 @@ package detour
 ///
 /// <b>Custom Implementations</b>
 ///
-/// DT_VIRTUAL_QUERYFILTER must be defined in order to extend this class.
+/// DtQueryFilter is an interface, so any caller of dtNavMeshQuery can supply
+/// its own implementation instead of DtDefaultQueryFilter. This is the Go
+/// equivalent of the DT_VIRTUAL_QUERYFILTER build option of the C++ library,
+/// without needing a compile-time switch: a filter that penalizes polygons
+/// near dynamic obstacles, or one that reads costs from an ECS component,
+/// can be passed to any query alongside the default one.
 ///
-/// Implement a custom query filter by overriding the virtual passFilter()
-/// and getCost() functions. If this is done, both functions should be as
-/// fast as possible. Use cached local copies of data rather than accessing
-/// your own objects where possible.
+/// Implement a custom query filter by implementing every method of
+/// DtQueryFilter (or by embedding *DtDefaultQueryFilter, see below), most
+/// often to change PassFilter() and GetCost(). If this is done, both
+/// methods should be as fast as possible.
+/// Use cached local copies of data rather than accessing your own objects
+/// where possible.
 ///
 /// Custom implementations do not need to adhere to the flags or cost logic
 /// used by the default implementation.
@@ -33,20 +39,121 @@ package detour
 /// the travel distance. Implementing a cost modifier less than 1.0 is likely
 /// to lead to problems during pathfinding.
 ///
+/// DtQueryFilter has grown methods beyond PassFilter()/GetCost() as later
+/// features needed them (backtracking, fixed area costs, heuristic scale,
+/// per-side link filtering), and any of those additions breaks a custom
+/// implementation written against an earlier, smaller version of this
+/// interface. A custom filter that wants to stay source-compatible across
+/// such additions should embed *DtDefaultQueryFilter and override only the
+/// methods it needs to change, rather than implementing every method
+/// itself.
+///
 /// @see dtNavMeshQuery
-type dtQueryFilter struct {
+type DtQueryFilter interface {
+	/// Returns true if the polygon can be visited.  (I.e. Is traversable.)
+	///  @param[in]		ref		The reference id of the polygon test.
+	///  @param[in]		tile	The tile containing the polygon.
+	///  @param[in]		poly  The polygon to test.
+	PassFilter(ref dtPolyRef, tile *DtMeshTile, poly *DtPoly) bool
+
+	/// Returns cost to move from the beginning to the end of a line segment
+	/// that is fully contained within a polygon.
+	///  @param[in]		pa			The start position on the edge of the previous and current polygon. [(x, y, z)]
+	///  @param[in]		pb			The end position on the edge of the current and next polygon. [(x, y, z)]
+	///  @param[in]		prevRef		The reference id of the previous polygon. [opt]
+	///  @param[in]		prevTile	The tile containing the previous polygon. [opt]
+	///  @param[in]		prevPoly	The previous polygon. [opt]
+	///  @param[in]		curRef		The reference id of the current polygon.
+	///  @param[in]		curTile		The tile containing the current polygon.
+	///  @param[in]		curPoly		The current polygon.
+	///  @param[in]		nextRef		The refernece id of the next polygon. [opt]
+	///  @param[in]		nextTile	The tile containing the next polygon. [opt]
+	///  @param[in]		nextPoly	The next polygon. [opt]
+	GetCost(pa, pb []float32,
+		prevRef dtPolyRef, prevTile *DtMeshTile, prevPoly *DtPoly,
+		curRef dtPolyRef, curTile *DtMeshTile, curPoly *DtPoly,
+		nextRef dtPolyRef, nextTile *DtMeshTile, nextPoly *DtPoly) float32
+
+	/// Returns the include flags for the filter.
+	/// Any polygons that include one or more of these flags will be
+	/// included in the operation.
+	GetIncludeFlags() uint16
+
+	/// Returns the exclude flags for the filter.
+	/// Any polygons that include one ore more of these flags will be
+	/// excluded from the operation.
+	GetExcludeFlags() uint16
+
+	/// Returns the traversal cost of the area.
+	///  @param[in]		i		The id of the area.
+	GetAreaCost(i int32) float32
+
+	/// Returns the fixed cost added once, on entry, whenever a polygon of
+	/// this area is crossed, independent of the length of the segment
+	/// travelled inside it.
+	///  @param[in]		i		The id of the area.
+	GetAreaFixedCost(i int32) float32
+
+	/// Returns true if the search using this filter is running in reverse,
+	/// i.e. from goal to start rather than start to goal.
+	/// DtDefaultQueryFilter's own GetCost() does not vary with this flag;
+	/// it is exposed so a custom filter with direction-sensitive costs
+	/// (e.g. one-way off-mesh connections) can query search direction
+	/// without an extra out-of-band parameter.
+	GetIsBacktracking() bool
+
+	/// Returns the scale applied to the euclidean heuristic in the A*
+	/// loop. 1.0 gives the standard admissible heuristic; values above
+	/// 1.0 trade optimality for speed (weighted A*).
+	GetHeuristicScale() float32
+
+	/// Returns true if links whose tile-boundary side equals side are
+	/// allowed to be traversed. Queries skip any link for which this
+	/// returns false during neighbour expansion, which lets a caller
+	/// restrict a search to a single tile, a directional wedge, or a
+	/// rectangular region of the world.
+	///  @param[in]		side	The tile-boundary side of the link, 0-7 (see DT_CONNECT_EXT_* in dtNavMesh), or 0xff for an internal (non-boundary) link.
+	IsValidLinkSide(side uint8) bool
+
+	/// Returns true if other has the same flags, area costs, and
+	/// extension fields as this filter, and therefore always produces
+	/// the same PassFilter/GetCost/IsValidLinkSide results for a given
+	/// navmesh. Callers such as a path cache use this to confirm a
+	/// fingerprint match is real rather than a hash collision, so every
+	/// implementation of DtQueryFilter must provide one.
+	///  @param[in]		other	The filter to compare against.
+	Equals(other DtQueryFilter) bool
+}
+
+/// DtDefaultQueryFilter is the flags-and-area-cost based DtQueryFilter used
+/// by dtNavMeshQuery unless the caller supplies its own.
+/// @see DtQueryFilter
+type DtDefaultQueryFilter struct {
 	///< Cost per area type. (Used by default implementation.)
 	m_areaCost [DT_MAX_AREAS]float32
+	///< Fixed cost added once on entry to a polygon of the area, regardless of segment length.
+	m_areaFixedCost [DT_MAX_AREAS]float32
 	///< Flags for polygons that can be visited. (Used by default implementation.)
 	m_includeFlags uint16
 	///< Flags for polygons that should not be visted. (Used by default implementation.)
 	m_excludeFlags uint16
+	///< True when the owning search is running from goal to start.
+	m_isBacktracking bool
+	///< Scale applied to the euclidean heuristic in the A* loop.
+	m_heuristicScale float32
+	///< Bitmask of the 8 tile-boundary sides this filter allows links to cross.
+	m_validLinkSides uint8
 }
 
-func newDtQueryFilter() *dtQueryFilter {
-	qf := dtQueryFilter{
-		m_includeFlags: 0xffff,
-		m_excludeFlags: 0,
+// DtDefaultQueryFilter must satisfy DtQueryFilter.
+var _ DtQueryFilter = (*DtDefaultQueryFilter)(nil)
+
+func NewDtQueryFilter() *DtDefaultQueryFilter {
+	qf := DtDefaultQueryFilter{
+		m_includeFlags:   0xffff,
+		m_excludeFlags:   0,
+		m_heuristicScale: 1.0,
+		m_validLinkSides: 0xff,
 	}
 	for i := int32(0); i < DT_MAX_AREAS; i++ {
 		qf.m_areaCost[i] = 1.0
@@ -57,36 +164,151 @@ func newDtQueryFilter() *dtQueryFilter {
 /// Returns the traversal cost of the area.
 ///  @param[in]		i		The id of the area.
 /// @returns The traversal cost of the area.
-func (qf *dtQueryFilter) getAreaCost(i int32) float32 { return qf.m_areaCost[i] }
+func (qf *DtDefaultQueryFilter) GetAreaCost(i int32) float32 { return qf.m_areaCost[i] }
 
 /// Sets the traversal cost of the area.
 ///  @param[in]		i		The id of the area.
 ///  @param[in]		cost	The new cost of traversing the area.
-func (qf *dtQueryFilter) setAreaCost(i int32, cost float32) { qf.m_areaCost[i] = cost }
+func (qf *DtDefaultQueryFilter) SetAreaCost(i int32, cost float32) { qf.m_areaCost[i] = cost }
+
+/// Returns the fixed cost added once, on entry, whenever a polygon of
+/// this area is crossed, independent of the length of the segment
+/// travelled inside it.
+///  @param[in]		i		The id of the area.
+func (qf *DtDefaultQueryFilter) GetAreaFixedCost(i int32) float32 { return qf.m_areaFixedCost[i] }
+
+/// Sets the fixed cost added once, on entry, whenever a polygon of this
+/// area is crossed. Lets designers express "opening this door costs 5
+/// units regardless of how far you walk across it."
+///  @param[in]		i		The id of the area.
+///  @param[in]		cost	The fixed cost of entering the area.
+func (qf *DtDefaultQueryFilter) SetAreaFixedCost(i int32, cost float32) { qf.m_areaFixedCost[i] = cost }
+
+/// Returns true if the search using this filter is running in reverse,
+/// i.e. from goal to start rather than start to goal.
+func (qf *DtDefaultQueryFilter) GetIsBacktracking() bool { return qf.m_isBacktracking }
+
+/// Sets whether the search using this filter is running in reverse.
+/// DtDefaultQueryFilter.GetCost() does not vary with this flag: the fixed
+/// per-area entry fee and the distance cost are both charged against
+/// curPoly, which is the polygon physically being crossed by a segment
+/// regardless of which direction the search is running. This flag is
+/// only meaningful to a custom filter that implements direction-sensitive
+/// costs (e.g. one-way off-mesh connections); DtDefaultQueryFilter simply
+/// stores and reports it.
+///  @param[in]		backtracking	True if the search is running from goal to start.
+func (qf *DtDefaultQueryFilter) SetIsBacktracking(backtracking bool) { qf.m_isBacktracking = backtracking }
+
+/// Returns the scale applied to the euclidean heuristic in the A* loop.
+func (qf *DtDefaultQueryFilter) GetHeuristicScale() float32 { return qf.m_heuristicScale }
+
+/// Sets the scale applied to the euclidean heuristic in the A* loop.
+/// 1.0 gives the standard admissible heuristic; values above 1.0 trade
+/// optimality for speed (weighted A*).
+///  @param[in]		scale	The heuristic scale factor.
+func (qf *DtDefaultQueryFilter) SetHeuristicScale(scale float32) { qf.m_heuristicScale = scale }
+
+/// Returns true if links whose tile-boundary side equals side are
+/// allowed to be traversed.
+///  @param[in]		side	The tile-boundary side of the link, 0-7, or 0xff for an internal link.
+func (qf *DtDefaultQueryFilter) IsValidLinkSide(side uint8) bool {
+	if side == 0xff {
+		return true
+	}
+	return qf.m_validLinkSides&(1<<side) != 0
+}
+
+/// Sets whether links whose tile-boundary side equals side are allowed
+/// to be traversed. Useful for restricting a query to a single tile, a
+/// directional wedge, or a rectangular region of the world (e.g. when
+/// only some neighbour tiles are loaded in a streaming world, or when
+/// splitting a large pathfind across worker goroutines that each own a
+/// sub-region without cross-boundary results).
+///  @param[in]		side	The tile-boundary side of the link, 0-7.
+///  @param[in]		valid	True to allow links crossing this side.
+func (qf *DtDefaultQueryFilter) SetIsValidLinkSide(side uint8, valid bool) {
+	if valid {
+		qf.m_validLinkSides |= 1 << side
+	} else {
+		qf.m_validLinkSides &^= 1 << side
+	}
+}
 
 /// Returns the include flags for the filter.
 /// Any polygons that include one or more of these flags will be
 /// included in the operation.
-func (qf *dtQueryFilter) getIncludeFlags() uint16 { return qf.m_includeFlags }
+func (qf *DtDefaultQueryFilter) GetIncludeFlags() uint16 { return qf.m_includeFlags }
 
 /// Sets the include flags for the filter.
 /// @param[in]		flags	The new flags.
-func (qf *dtQueryFilter) setIncludeFlags(flags uint16) { qf.m_includeFlags = flags }
+func (qf *DtDefaultQueryFilter) SetIncludeFlags(flags uint16) { qf.m_includeFlags = flags }
 
 /// Returns the exclude flags for the filter.
 /// Any polygons that include one ore more of these flags will be
 /// excluded from the operation.
-func (qf *dtQueryFilter) getExcludeFlags() uint16 { return qf.m_excludeFlags }
+func (qf *DtDefaultQueryFilter) GetExcludeFlags() uint16 { return qf.m_excludeFlags }
 
 /// Sets the exclude flags for the filter.
 /// @param[in]		flags		The new flags.
-func (qf *dtQueryFilter) setExcludeFlags(flags uint16) { qf.m_excludeFlags = flags }
+func (qf *DtDefaultQueryFilter) SetExcludeFlags(flags uint16) { qf.m_excludeFlags = flags }
+
+/// Returns true if other has the same flags, area costs, and extension
+/// fields (backtracking, fixed costs, heuristic scale, valid link sides)
+/// as qf. Two equal filters always produce the same PassFilter/GetCost
+/// results for a given navmesh. other is read entirely through the
+/// DtQueryFilter interface, so this also works against a custom
+/// implementation, not just another DtDefaultQueryFilter.
+///  @param[in]		other	The filter to compare against.
+func (qf *DtDefaultQueryFilter) Equals(other DtQueryFilter) bool {
+	if other == nil {
+		return false
+	}
+	if qf.m_includeFlags != other.GetIncludeFlags() ||
+		qf.m_excludeFlags != other.GetExcludeFlags() ||
+		qf.m_isBacktracking != other.GetIsBacktracking() ||
+		qf.m_heuristicScale != other.GetHeuristicScale() {
+		return false
+	}
+	for i := int32(0); i < DT_MAX_AREAS; i++ {
+		if qf.m_areaCost[i] != other.GetAreaCost(i) || qf.m_areaFixedCost[i] != other.GetAreaFixedCost(i) {
+			return false
+		}
+	}
+	for side := uint8(0); side < 8; side++ {
+		if qf.IsValidLinkSide(side) != other.IsValidLinkSide(side) {
+			return false
+		}
+	}
+	return true
+}
+
+/// Copies other's flags, area costs, and extension fields into qf,
+/// replacing qf's current values. other is read entirely through the
+/// DtQueryFilter interface, so any implementation can be copied from.
+///  @param[in]		other	The filter to copy from.
+func (qf *DtDefaultQueryFilter) CopyFrom(other DtQueryFilter) {
+	qf.m_includeFlags = other.GetIncludeFlags()
+	qf.m_excludeFlags = other.GetExcludeFlags()
+	qf.m_isBacktracking = other.GetIsBacktracking()
+	qf.m_heuristicScale = other.GetHeuristicScale()
+	for i := int32(0); i < DT_MAX_AREAS; i++ {
+		qf.m_areaCost[i] = other.GetAreaCost(i)
+		qf.m_areaFixedCost[i] = other.GetAreaFixedCost(i)
+	}
+	var sides uint8
+	for side := uint8(0); side < 8; side++ {
+		if other.IsValidLinkSide(side) {
+			sides |= 1 << side
+		}
+	}
+	qf.m_validLinkSides = sides
+}
 
 /// Returns true if the polygon can be visited.  (I.e. Is traversable.)
 ///  @param[in]		ref		The reference id of the polygon test.
 ///  @param[in]		tile	The tile containing the polygon.
 ///  @param[in]		poly  The polygon to test.
-func (qf *dtQueryFilter) passFilter(ref dtPolyRef,
+func (qf *DtDefaultQueryFilter) PassFilter(ref dtPolyRef,
 	tile *DtMeshTile,
 	poly *DtPoly) bool {
 
@@ -107,10 +329,22 @@ func (qf *dtQueryFilter) passFilter(ref dtPolyRef,
 ///  @param[in]		nextTile	The tile containing the next polygon. [opt]
 ///  @param[in]		nextPoly	The next polygon. [opt]
 
-func (qf *dtQueryFilter) getCost(pa, pb []float32,
+func (qf *DtDefaultQueryFilter) GetCost(pa, pb []float32,
 	prevRef dtPolyRef, prevTile *DtMeshTile, prevPoly *DtPoly,
 	curRef dtPolyRef, curTile *DtMeshTile, curPoly *DtPoly,
 	nextRef dtPolyRef, nextTile *DtMeshTile, nextPoly *DtPoly) float32 {
 
-	return dtVdist(pa, pb) * qf.m_areaCost[curPoly.Area()]
+	// The fixed entry fee and the distance cost are both charged against
+	// curPoly: it is the polygon physically being crossed by this
+	// segment regardless of which direction the search is running, so
+	// neither term should move to prevPoly/nextPoly when backtracking.
+	return qf.areaCost(int32(curPoly.Area()), dtVdist(pa, pb))
+}
+
+// areaCost computes the fixed entry fee plus distance-scaled cost for
+// crossing a polygon whose area cost index is i, over a segment of
+// length dist. Split out of GetCost so this arithmetic can be unit tested
+// directly, without needing a real *DtPoly/*DtMeshTile.
+func (qf *DtDefaultQueryFilter) areaCost(i int32, dist float32) float32 {
+	return qf.m_areaFixedCost[i] + dist*qf.m_areaCost[i]
 }
@@ -0,0 +1,28 @@
+package detour
+
+import "testing"
+
+func TestDtDefaultQueryFilterEqualsAndCopyFrom(t *testing.T) {
+	a := NewDtQueryFilter()
+	b := NewDtQueryFilter()
+	if !a.Equals(b) {
+		t.Fatal("two freshly constructed filters should be equal")
+	}
+
+	b.SetAreaCost(1, 3)
+	b.SetAreaFixedCost(2, 4)
+	b.SetIncludeFlags(0x1)
+	b.SetExcludeFlags(0x2)
+	b.SetIsBacktracking(true)
+	b.SetHeuristicScale(1.5)
+	b.SetIsValidLinkSide(0, false)
+
+	if a.Equals(b) {
+		t.Fatal("filters with different fields should not be equal")
+	}
+
+	a.CopyFrom(b)
+	if !a.Equals(b) {
+		t.Fatal("a should equal b after a.CopyFrom(b)")
+	}
+}
@@ -0,0 +1,19 @@
+package detour
+
+import "testing"
+
+func TestDtDefaultQueryFilterValidLinkSideDefaultsAllValid(t *testing.T) {
+	qf := NewDtQueryFilter()
+	for side := uint8(0); side < 8; side++ {
+		if !qf.IsValidLinkSide(side) {
+			t.Fatalf("side %d should be valid by default", side)
+		}
+	}
+	qf.SetIsValidLinkSide(2, false)
+	if qf.IsValidLinkSide(2) {
+		t.Fatal("side 2 should be invalid after SetIsValidLinkSide(2, false)")
+	}
+	if !qf.IsValidLinkSide(3) {
+		t.Fatal("side 3 should remain valid")
+	}
+}
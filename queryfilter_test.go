@@ -0,0 +1,59 @@
+package detour
+
+import "testing"
+
+func TestDtDefaultQueryFilterHeuristicScaleRoundTrip(t *testing.T) {
+	qf := NewDtQueryFilter()
+	if got := qf.GetHeuristicScale(); got != 1.0 {
+		t.Fatalf("default heuristic scale = %v, want 1.0", got)
+	}
+	qf.SetHeuristicScale(2.5)
+	if got := qf.GetHeuristicScale(); got != 2.5 {
+		t.Fatalf("GetHeuristicScale() = %v, want 2.5 after SetHeuristicScale(2.5)", got)
+	}
+}
+
+func TestDtDefaultQueryFilterBacktrackingRoundTrip(t *testing.T) {
+	qf := NewDtQueryFilter()
+	if qf.GetIsBacktracking() {
+		t.Fatal("default filter should not be backtracking")
+	}
+	qf.SetIsBacktracking(true)
+	if !qf.GetIsBacktracking() {
+		t.Fatal("GetIsBacktracking() should be true after SetIsBacktracking(true)")
+	}
+}
+
+func TestDtDefaultQueryFilterAreaFixedCostRoundTrip(t *testing.T) {
+	qf := NewDtQueryFilter()
+	if got := qf.GetAreaFixedCost(3); got != 0 {
+		t.Fatalf("default area fixed cost = %v, want 0", got)
+	}
+	qf.SetAreaFixedCost(3, 5)
+	if got := qf.GetAreaFixedCost(3); got != 5 {
+		t.Fatalf("GetAreaFixedCost(3) = %v, want 5 after SetAreaFixedCost(3, 5)", got)
+	}
+}
+
+// areaCost holds all of GetCost's cost arithmetic; it must return the same
+// value for the same
+// (area, distance) pair regardless of whether the owning search is marked
+// as backtracking, since curPoly itself never swaps.
+func TestDtDefaultQueryFilterAreaCostIsDirectionIndependent(t *testing.T) {
+	qf := NewDtQueryFilter()
+	qf.SetAreaFixedCost(1, 100)
+	qf.SetAreaFixedCost(2, 10)
+	qf.SetAreaCost(2, 2)
+
+	forward := qf.areaCost(2, 4)
+
+	qf.SetIsBacktracking(true)
+	backtracking := qf.areaCost(2, 4)
+
+	if forward != backtracking {
+		t.Fatalf("areaCost(2, 4) = %v forward, %v backtracking; want equal, since crossing area 2 costs the same regardless of search direction", forward, backtracking)
+	}
+	if want := float32(18); forward != want {
+		t.Fatalf("areaCost(2, 4) = %v, want %v (fixed 10 + dist 4 * areaCost 2)", forward, want)
+	}
+}